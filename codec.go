@@ -0,0 +1,157 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+)
+
+// dhGroupJSON is the wire shape for DHGroup.MarshalJSON / UnmarshalJSON. p,
+// g and q (when present) are encoded as base64 of their big-endian bytes.
+type dhGroupJSON struct {
+	P string `json:"p"`
+	G string `json:"g"`
+	Q string `json:"q,omitempty"`
+}
+
+// MarshalJSON encodes the group's p, g, and (if known) q as base64
+// big-endian integers, so a DHGroup can be persisted to disk alongside the
+// keys generated from it.
+func (h *DHGroup) MarshalJSON() ([]byte, error) {
+	if h.p == nil || h.g == nil {
+		return nil, errors.New("DH: invalid group")
+	}
+	out := dhGroupJSON{
+		P: base64.StdEncoding.EncodeToString(h.p.Bytes()),
+		G: base64.StdEncoding.EncodeToString(h.g.Bytes()),
+	}
+	if h.q != nil {
+		out.Q = base64.StdEncoding.EncodeToString(h.q.Bytes())
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON.
+func (h *DHGroup) UnmarshalJSON(data []byte) error {
+	var in dhGroupJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	p, err := decodeBase64BigInt(in.P)
+	if err != nil {
+		return err
+	}
+	g, err := decodeBase64BigInt(in.G)
+	if err != nil {
+		return err
+	}
+	h.p = p
+	h.g = g
+	h.q = nil
+	if in.Q != "" {
+		q, err := decodeBase64BigInt(in.Q)
+		if err != nil {
+			return err
+		}
+		h.q = q
+	}
+	return nil
+}
+
+// dhKeyJSON is the wire shape for DHKey.MarshalJSON / UnmarshalJSON. x is
+// only present for a private key.
+type dhKeyJSON struct {
+	X string `json:"x,omitempty"`
+	Y string `json:"y"`
+}
+
+// MarshalJSON encodes the key's public value Y, and its private value X if
+// present, as base64 big-endian integers. Group is not included; callers
+// persisting a key alongside its DHGroup (see the dhkx/wire package's
+// LoadOrGenerate) are expected to already know which group it belongs to.
+func (h *DHKey) MarshalJSON() ([]byte, error) {
+	if h.Y == nil {
+		return nil, errors.New("DH: invalid key")
+	}
+	out := dhKeyJSON{Y: base64.StdEncoding.EncodeToString(h.Y.Bytes())}
+	if h.X != nil {
+		out.X = base64.StdEncoding.EncodeToString(h.X.Bytes())
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON. It does not set Group; the
+// caller must do so once it knows which group the key belongs to.
+func (h *DHKey) UnmarshalJSON(data []byte) error {
+	var in dhKeyJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	y, err := decodeBase64BigInt(in.Y)
+	if err != nil {
+		return err
+	}
+	h.Y = y
+	h.X = nil
+	if in.X != "" {
+		x, err := decodeBase64BigInt(in.X)
+		if err != nil {
+			return err
+		}
+		h.X = x
+	}
+	return nil
+}
+
+func decodeBase64BigInt(s string) (*big.Int, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// pemBlockType is the PEM block type MarshalPEM and UnmarshalPEM use.
+const pemBlockType = "DH PUBLIC KEY"
+
+// MarshalPEM encodes the public half of the key as a "DH PUBLIC KEY" PEM
+// block, using the same modulus-length padding as MarshalPublicKey. h.Group
+// must be set, since the encoded length depends on the modulus.
+func (h *DHKey) MarshalPEM() ([]byte, error) {
+	if h.Y == nil {
+		return nil, errors.New("DH: invalid public key")
+	}
+	if h.Group == nil {
+		return nil, errors.New("DH: key has no group, cannot determine encoded length")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemBlockType, Bytes: h.MarshalPublicKey()}), nil
+}
+
+// UnmarshalPEM parses a "DH PUBLIC KEY" PEM block produced by MarshalPEM,
+// associating the result with group.
+func UnmarshalPEM(data []byte, group *DHGroup) (*DHKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemBlockType {
+		return nil, errors.New("DH: not a DH PUBLIC KEY PEM block")
+	}
+	return UnmarshalUniform(block.Bytes, group)
+}
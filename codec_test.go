@@ -0,0 +1,89 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDHGroupJSONRoundTrip(t *testing.T) {
+	group, _ := GetGroup(DHKX_FFDHE2048)
+
+	data, err := json.Marshal(group)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got DHGroup
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got.P().Cmp(group.P()) != 0 {
+		t.Errorf("p did not round-trip")
+	}
+	if got.G().Cmp(group.G()) != 0 {
+		t.Errorf("g did not round-trip")
+	}
+	if got.Q() == nil || got.Q().Cmp(group.Q()) != 0 {
+		t.Errorf("q did not round-trip")
+	}
+}
+
+func TestDHKeyJSONRoundTrip(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	key, _ := group.GeneratePrivateKey(nil)
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got DHKey
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got.X.Cmp(key.X) != 0 {
+		t.Errorf("x did not round-trip")
+	}
+	if got.Y.Cmp(key.Y) != 0 {
+		t.Errorf("y did not round-trip")
+	}
+}
+
+func TestDHKeyPEMRoundTrip(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	key, _ := group.GeneratePrivateKey(nil)
+
+	data, err := key.MarshalPEM()
+	if err != nil {
+		t.Fatalf("MarshalPEM: %v", err)
+	}
+	if !bytes.Contains(data, []byte("BEGIN DH PUBLIC KEY")) {
+		t.Errorf("expected a DH PUBLIC KEY PEM block, got %s", data)
+	}
+
+	got, err := UnmarshalPEM(data, group)
+	if err != nil {
+		t.Fatalf("UnmarshalPEM: %v", err)
+	}
+	if !bytes.Equal(got.MarshalPublicKey(), key.MarshalPublicKey()) {
+		t.Errorf("decoded public key does not match the original")
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+)
+
+// constantTimeExp computes base^exp mod mod using a Montgomery powering
+// ladder: for every bit of exp, in order from most to least significant,
+// both branches below perform exactly one multiplication and one squaring,
+// so the sequence of big.Int operations does not depend on exp's bit
+// pattern. This avoids the most direct way GeneratePrivateKey and
+// ComputeKey could leak a private exponent's bits to a co-tenant measuring
+// wall-clock time, which matters because a DH private key is typically
+// reused across many handshakes.
+//
+// This does not make big.Int itself constant-time at the machine-word
+// level (its word-limb multiplication and comparisons are not documented as
+// data-independent), so it narrows rather than eliminates the timing
+// surface; it replaces big.Int.Exp's variable-width windowing, which is the
+// part of the computation most directly keyed off the exponent's bits.
+func constantTimeExp(base, exp, mod *big.Int) *big.Int {
+	return constantTimeExpScratch(base, exp, mod, new(big.Int), new(big.Int))
+}
+
+// constantTimeExpScratch is constantTimeExp with its two ladder registers
+// supplied by the caller, so that repeated calls (see ScratchBuffer) reuse
+// the same two big.Int registers. The multiplications and reductions inside
+// the ladder still allocate their intermediate results on every call.
+func constantTimeExpScratch(base, exp, mod, r0, r1 *big.Int) *big.Int {
+	r0.SetInt64(1)
+	r1.Set(base)
+	r1.Mod(r1, mod)
+
+	for i := exp.BitLen() - 1; i >= 0; i-- {
+		if exp.Bit(i) == 0 {
+			r1.Mul(r0, r1)
+			r1.Mod(r1, mod)
+			r0.Mul(r0, r0)
+			r0.Mod(r0, mod)
+		} else {
+			r0.Mul(r0, r1)
+			r0.Mod(r0, mod)
+			r1.Mul(r1, r1)
+			r1.Mod(r1, mod)
+		}
+	}
+	return r0
+}
+
+// ScratchBuffer holds the two big.Int ladder registers
+// GeneratePrivateKeyWithScratch and ComputeKeyWithScratch use internally, so
+// that a caller negotiating many sessions per second (e.g. a WebAuthn CTAP2
+// hub fanning out to many authenticators) can reuse one buffer across
+// handshakes instead of allocating those two registers on every call. It does
+// not remove the allocations made by the big.Int multiplications and
+// reductions inside the ladder, which account for nearly all of a
+// handshake's allocations. A ScratchBuffer must not be used concurrently by
+// more than one handshake at a time.
+type ScratchBuffer struct {
+	r0, r1 big.Int
+}
+
+// NewScratchBuffer allocates a ScratchBuffer ready for use with
+// GeneratePrivateKeyWithScratch and ComputeKeyWithScratch.
+func NewScratchBuffer() *ScratchBuffer {
+	return new(ScratchBuffer)
+}
+
+// GeneratePrivateKeyWithScratch behaves like DHGroup.GeneratePrivateKey, but
+// performs its modular exponentiation using scratch's preallocated
+// registers instead of allocating new ones. Pass nil to allocate a
+// throwaway buffer, which makes this equivalent to GeneratePrivateKey.
+func (h *DHGroup) GeneratePrivateKeyWithScratch(randReader io.Reader, scratch *ScratchBuffer) (key *DHKey, err error) {
+	if randReader == nil {
+		randReader = rand.Reader
+	}
+	if scratch == nil {
+		scratch = NewScratchBuffer()
+	}
+
+	x, err := randNonZero(randReader, h.p)
+	if err != nil {
+		return
+	}
+
+	key = new(DHKey)
+	key.X = x
+	key.Y = new(big.Int).Set(constantTimeExpScratch(h.g, x, h.p, &scratch.r0, &scratch.r1))
+	key.Group = h
+	return
+}
+
+// ComputeKeyWithScratch behaves like DHGroup.ComputeKey, but performs its
+// modular exponentiation using scratch's preallocated registers instead of
+// allocating new ones. Pass nil to allocate a throwaway buffer, which makes
+// this equivalent to ComputeKey.
+func (h *DHGroup) ComputeKeyWithScratch(pubkey *DHKey, privkey *DHKey, scratch *ScratchBuffer) (key *DHKey, err error) {
+	if err = validateComputeKeyArgs(h, pubkey, privkey); err != nil {
+		return
+	}
+	if scratch == nil {
+		scratch = NewScratchBuffer()
+	}
+
+	key = new(DHKey)
+	key.Y = new(big.Int).Set(constantTimeExpScratch(pubkey.Y, privkey.X, h.p, &scratch.r0, &scratch.r1))
+	key.Group = h
+	return
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestConstantTimeExpMatchesBigIntExp(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	base := group.G()
+	exp, _ := group.GeneratePrivateKey(nil)
+
+	got := constantTimeExp(base, exp.X, group.p)
+	want := new(big.Int).Exp(base, exp.X, group.p)
+	if got.Cmp(want) != 0 {
+		t.Errorf("constantTimeExp disagrees with big.Int.Exp: got %s, want %s", got.String(), want.String())
+	}
+}
+
+func TestGeneratePrivateKeyWithScratchKeyExchange(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	scratch := NewScratchBuffer()
+
+	priv1, err := group.GeneratePrivateKeyWithScratch(nil, scratch)
+	if err != nil {
+		t.Fatalf("GeneratePrivateKeyWithScratch: %v", err)
+	}
+	priv2, err := group.GeneratePrivateKeyWithScratch(nil, scratch)
+	if err != nil {
+		t.Fatalf("GeneratePrivateKeyWithScratch: %v", err)
+	}
+
+	pub1 := NewPublicKey(priv1.MarshalPublicKey())
+	pub2 := NewPublicKey(priv2.MarshalPublicKey())
+
+	key1, err := group.ComputeKeyWithScratch(pub2, priv1, scratch)
+	if err != nil {
+		t.Fatalf("ComputeKeyWithScratch: %v", err)
+	}
+	key2, err := group.ComputeKeyWithScratch(pub1, priv2, scratch)
+	if err != nil {
+		t.Fatalf("ComputeKeyWithScratch: %v", err)
+	}
+
+	if key1.Y.Cmp(key2.Y) != 0 {
+		t.Errorf("shared secrets do not match: %s != %s", key1.Y.String(), key2.Y.String())
+	}
+}
+
+func TestWipeZeroesKey(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	key, _ := group.GeneratePrivateKey(nil)
+
+	key.Wipe()
+
+	if key.X.Sign() != 0 {
+		t.Errorf("expected X to be wiped to 0, got %s", key.X.String())
+	}
+	if key.Y.Sign() != 0 {
+		t.Errorf("expected Y to be wiped to 0, got %s", key.Y.String())
+	}
+}
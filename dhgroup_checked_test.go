@@ -0,0 +1,89 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFFDHEGroupsHaveKnownSubgroupOrder(t *testing.T) {
+	ids := []GroupID{DHKX_FFDHE2048, DHKX_FFDHE3072, DHKX_FFDHE4096, DHKX_FFDHE6144, DHKX_FFDHE8192}
+	for _, id := range ids {
+		group, err := GetGroup(id)
+		if err != nil {
+			t.Fatalf("GetGroup(%d): %v", id, err)
+		}
+		if group.Q() == nil {
+			t.Errorf("GetGroup(%d): expected a known subgroup order", id)
+		}
+		if group.P().BitLen() != int(id) {
+			t.Errorf("GetGroup(%d): expected a %d-bit prime, got %d bits", id, id, group.P().BitLen())
+		}
+	}
+}
+
+func TestGeneratePrivateKeyBoundedKeyExchange(t *testing.T) {
+	group, _ := GetGroup(DHKX_FFDHE2048)
+
+	priv1, err := group.GeneratePrivateKeyBounded(nil)
+	if err != nil {
+		t.Fatalf("GeneratePrivateKeyBounded: %v", err)
+	}
+	priv2, err := group.GeneratePrivateKeyBounded(nil)
+	if err != nil {
+		t.Fatalf("GeneratePrivateKeyBounded: %v", err)
+	}
+
+	pub1 := NewPublicKey(priv1.MarshalPublicKey())
+	pub2 := NewPublicKey(priv2.MarshalPublicKey())
+
+	key1, err := group.ComputeKeyChecked(pub2, priv1)
+	if err != nil {
+		t.Fatalf("ComputeKeyChecked: %v", err)
+	}
+	key2, err := group.ComputeKeyChecked(pub1, priv2)
+	if err != nil {
+		t.Fatalf("ComputeKeyChecked: %v", err)
+	}
+
+	if key1.Y.Cmp(key2.Y) != 0 {
+		t.Errorf("shared secrets do not match: %s != %s", key1.Y.String(), key2.Y.String())
+	}
+}
+
+func TestComputeKeyCheckedRejectsSmallSubgroup(t *testing.T) {
+	group, _ := GetGroup(DHKX_FFDHE2048)
+	priv, _ := group.GeneratePrivateKeyBounded(nil)
+
+	for _, y := range []*big.Int{big.NewInt(1), group.P().Sub(group.P(), big.NewInt(1))} {
+		pub := NewPublicKey(y.Bytes())
+		if _, err := group.ComputeKeyChecked(pub, priv); err == nil {
+			t.Errorf("expected ComputeKeyChecked to reject Y=%s", y.String())
+		}
+	}
+}
+
+func TestComputeKeyCheckedRequiresKnownSubgroupOrder(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	priv, _ := group.GeneratePrivateKey(nil)
+	pub := NewPublicKey(priv.MarshalPublicKey())
+	if _, err := group.ComputeKeyChecked(pub, priv); err == nil {
+		t.Errorf("expected ComputeKeyChecked to fail without a known subgroup order")
+	}
+}
@@ -59,6 +59,29 @@ func NewPublicKey(s []byte) *DHKey {
 	return key
 }
 
+// Wipe zeros the words backing X and Y in place, so that private key
+// material does not linger in memory after a handshake is done with it.
+// Call it as soon as the key is no longer needed, rather than waiting on
+// the garbage collector to reclaim it. After Wipe, X and Y are both 0 and
+// the key must not be used again.
+func (h *DHKey) Wipe() {
+	wipeBigInt(h.X)
+	wipeBigInt(h.Y)
+}
+
+// wipeBigInt overwrites x's backing word slice with zeros before resetting
+// x to 0, so the secret bytes do not survive in the freed/reused memory.
+func wipeBigInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	x.SetBits(words)
+}
+
 // copyWithLeftPad copies src to the end of dest, padding with zero bytes as
 // needed.
 func copyWithLeftPad(dest, src []byte) {
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import "testing"
+
+// BenchmarkGeneratePrivateKey_MODP2048 and its MODP3072 counterpart measure
+// the cost of the constant-time modexp path introduced alongside
+// ComputeKeyWithScratch; compare against the *_WithScratch benchmarks below
+// to see how reusing a ScratchBuffer across calls changes the allocation
+// count.
+func BenchmarkGeneratePrivateKey_MODP2048(b *testing.B) {
+	benchmarkGeneratePrivateKey(b, DHKX_ID14)
+}
+
+func BenchmarkGeneratePrivateKey_MODP3072(b *testing.B) {
+	benchmarkGeneratePrivateKey(b, DHKX_ID15)
+}
+
+func BenchmarkGeneratePrivateKeyWithScratch_MODP2048(b *testing.B) {
+	benchmarkGeneratePrivateKeyWithScratch(b, DHKX_ID14)
+}
+
+func BenchmarkGeneratePrivateKeyWithScratch_MODP3072(b *testing.B) {
+	benchmarkGeneratePrivateKeyWithScratch(b, DHKX_ID15)
+}
+
+func BenchmarkComputeKey_MODP2048(b *testing.B) {
+	benchmarkComputeKey(b, DHKX_ID14)
+}
+
+func BenchmarkComputeKey_MODP3072(b *testing.B) {
+	benchmarkComputeKey(b, DHKX_ID15)
+}
+
+func BenchmarkComputeKeyWithScratch_MODP2048(b *testing.B) {
+	benchmarkComputeKeyWithScratch(b, DHKX_ID14)
+}
+
+func BenchmarkComputeKeyWithScratch_MODP3072(b *testing.B) {
+	benchmarkComputeKeyWithScratch(b, DHKX_ID15)
+}
+
+func benchmarkGeneratePrivateKey(b *testing.B, id GroupID) {
+	group, err := GetGroup(id)
+	if err != nil {
+		b.Fatalf("GetGroup: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := group.GeneratePrivateKey(nil); err != nil {
+			b.Fatalf("GeneratePrivateKey: %v", err)
+		}
+	}
+}
+
+func benchmarkGeneratePrivateKeyWithScratch(b *testing.B, id GroupID) {
+	group, err := GetGroup(id)
+	if err != nil {
+		b.Fatalf("GetGroup: %v", err)
+	}
+	scratch := NewScratchBuffer()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := group.GeneratePrivateKeyWithScratch(nil, scratch); err != nil {
+			b.Fatalf("GeneratePrivateKeyWithScratch: %v", err)
+		}
+	}
+}
+
+func benchmarkComputeKey(b *testing.B, id GroupID) {
+	group, err := GetGroup(id)
+	if err != nil {
+		b.Fatalf("GetGroup: %v", err)
+	}
+	priv, _ := group.GeneratePrivateKey(nil)
+	peer, _ := group.GeneratePrivateKey(nil)
+	pub := NewPublicKey(peer.MarshalPublicKey())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := group.ComputeKey(pub, priv); err != nil {
+			b.Fatalf("ComputeKey: %v", err)
+		}
+	}
+}
+
+func benchmarkComputeKeyWithScratch(b *testing.B, id GroupID) {
+	group, err := GetGroup(id)
+	if err != nil {
+		b.Fatalf("GetGroup: %v", err)
+	}
+	priv, _ := group.GeneratePrivateKey(nil)
+	peer, _ := group.GeneratePrivateKey(nil)
+	pub := NewPublicKey(peer.MarshalPublicKey())
+	scratch := NewScratchBuffer()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := group.ComputeKeyWithScratch(pub, priv, scratch); err != nil {
+			b.Fatalf("ComputeKeyWithScratch: %v", err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// KDF selects the key derivation function DeriveKeys and ComputeSessionKeys
+// use to turn a raw Diffie-Hellman shared secret into symmetric key
+// material.
+type KDF int
+
+const (
+	// KDF_HKDF_SHA256 is HKDF (RFC 5869) with an empty salt, instantiated
+	// with HMAC-SHA256.
+	KDF_HKDF_SHA256 KDF = iota + 1
+	// KDF_HKDF_SHA512 is HKDF (RFC 5869) with an empty salt, instantiated
+	// with HMAC-SHA512.
+	KDF_HKDF_SHA512
+	// KDF_SP800_56C_SHA256 is the NIST SP 800-56C one-step KDF,
+	// instantiated with SHA-256.
+	KDF_SP800_56C_SHA256
+	// KDF_SP800_56C_SHA512 is the NIST SP 800-56C one-step KDF,
+	// instantiated with SHA-512.
+	KDF_SP800_56C_SHA512
+)
+
+func (k KDF) newHash() func() hash.Hash {
+	switch k {
+	case KDF_HKDF_SHA256, KDF_SP800_56C_SHA256:
+		return sha256.New
+	case KDF_HKDF_SHA512, KDF_SP800_56C_SHA512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// DeriveKeys derives outLen bytes of symmetric session key material from
+// shared, a DH result such as one returned by DHGroup.ComputeKey. Callers
+// should not use shared's raw bytes directly; every caller of ComputeKey
+// ends up hashing its output before use anyway, and info lets unrelated
+// uses of the same shared secret (e.g. separate encrypt/MAC keys, or
+// distinguishing two protocol roles) derive independent key material from
+// it.
+//
+// shared.Group must be set, since the input to the KDF is the modulus-length
+// left-padded big-endian encoding of shared.Y (the same encoding
+// MarshalPublicKey already produces for public values), which keeps this
+// interoperable with TLS-style and SSH-style peers that encode the shared
+// secret the same way.
+func DeriveKeys(shared *DHKey, kdf KDF, info []byte, outLen int) ([]byte, error) {
+	if outLen <= 0 {
+		return nil, errors.New("DH: outLen must be positive")
+	}
+	newHash := kdf.newHash()
+	if newHash == nil {
+		return nil, errors.New("DH: unknown KDF")
+	}
+	if shared == nil || shared.Y == nil {
+		return nil, errors.New("DH: invalid shared secret")
+	}
+	if shared.Group == nil {
+		return nil, errors.New("DH: shared secret has no group, cannot determine its encoded length")
+	}
+	secret := shared.MarshalPublicKey()
+
+	switch kdf {
+	case KDF_HKDF_SHA256, KDF_HKDF_SHA512:
+		return hkdf(newHash, secret, info, outLen)
+	case KDF_SP800_56C_SHA256, KDF_SP800_56C_SHA512:
+		return oneStepKDF(newHash, secret, info, outLen)
+	default:
+		return nil, errors.New("DH: unknown KDF")
+	}
+}
+
+// ComputeSessionKeys computes the DH shared secret for pub and priv, derives
+// outLen bytes of session key material from it via kdf and info, and wipes
+// the raw shared secret before returning, so the raw group element g^{xy}
+// mod p is never exposed to application code. This mirrors the pattern used
+// by the ntor and obfs4 handshakes.
+func ComputeSessionKeys(pub, priv *DHKey, kdf KDF, info []byte, outLen int) ([]byte, error) {
+	if priv == nil || priv.Group == nil {
+		return nil, errors.New("DH: invalid private key")
+	}
+	shared, err := priv.Group.ComputeKey(pub, priv)
+	if err != nil {
+		return nil, err
+	}
+	defer shared.Wipe()
+	return DeriveKeys(shared, kdf, info, outLen)
+}
+
+// hkdf implements RFC 5869 HKDF-Extract and HKDF-Expand with an empty salt,
+// which HKDF defines as equivalent to a salt of HashLen zero bytes.
+func hkdf(newHash func() hash.Hash, secret, info []byte, outLen int) ([]byte, error) {
+	hashLen := newHash().Size()
+	n := (outLen + hashLen - 1) / hashLen
+	if n > 255 {
+		return nil, errors.New("DH: requested key length too long for HKDF")
+	}
+
+	salt := make([]byte, hashLen)
+	extract := hmac.New(newHash, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(newHash, prk)
+	okm := make([]byte, 0, n*hashLen)
+	var prev []byte
+	for i := 1; i <= n; i++ {
+		expand.Reset()
+		expand.Write(prev)
+		expand.Write(info)
+		expand.Write([]byte{byte(i)})
+		prev = expand.Sum(nil)
+		okm = append(okm, prev...)
+	}
+	return okm[:outLen], nil
+}
+
+// oneStepKDF implements the NIST SP 800-56C one-step KDF: H(counter || Z ||
+// FixedInfo), with FixedInfo taken to be info and the counter a 4-byte
+// big-endian value starting at 1, repeated until outLen bytes are produced.
+func oneStepKDF(newHash func() hash.Hash, secret, info []byte, outLen int) ([]byte, error) {
+	h := newHash()
+	hashLen := h.Size()
+	n := (outLen + hashLen - 1) / hashLen
+
+	okm := make([]byte, 0, n*hashLen)
+	counter := make([]byte, 4)
+	for i := 1; i <= n; i++ {
+		binary.BigEndian.PutUint32(counter, uint32(i))
+		h.Reset()
+		h.Write(counter)
+		h.Write(secret)
+		h.Write(info)
+		okm = h.Sum(okm)
+	}
+	return okm[:outLen], nil
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeriveKeysLengthAndDeterminism(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	priv, _ := group.GeneratePrivateKey(nil)
+	peer, _ := group.GeneratePrivateKey(nil)
+	shared, err := group.ComputeKey(NewPublicKey(peer.MarshalPublicKey()), priv)
+	if err != nil {
+		t.Fatalf("ComputeKey: %v", err)
+	}
+
+	kdfs := []KDF{KDF_HKDF_SHA256, KDF_HKDF_SHA512, KDF_SP800_56C_SHA256, KDF_SP800_56C_SHA512}
+	for _, kdf := range kdfs {
+		out1, err := DeriveKeys(shared, kdf, []byte("test info"), 48)
+		if err != nil {
+			t.Fatalf("DeriveKeys(%d): %v", kdf, err)
+		}
+		if len(out1) != 48 {
+			t.Errorf("DeriveKeys(%d): expected 48 bytes, got %d", kdf, len(out1))
+		}
+		out2, err := DeriveKeys(shared, kdf, []byte("test info"), 48)
+		if err != nil {
+			t.Fatalf("DeriveKeys(%d): %v", kdf, err)
+		}
+		if !bytes.Equal(out1, out2) {
+			t.Errorf("DeriveKeys(%d): expected deterministic output for identical inputs", kdf)
+		}
+
+		other, err := DeriveKeys(shared, kdf, []byte("different info"), 48)
+		if err != nil {
+			t.Fatalf("DeriveKeys(%d): %v", kdf, err)
+		}
+		if bytes.Equal(out1, other) {
+			t.Errorf("DeriveKeys(%d): expected different info to change the output", kdf)
+		}
+	}
+}
+
+func TestDeriveKeysRequiresGroup(t *testing.T) {
+	shared := NewPublicKey([]byte{1, 2, 3})
+	if _, err := DeriveKeys(shared, KDF_HKDF_SHA256, nil, 32); err == nil {
+		t.Errorf("expected DeriveKeys to fail for a shared secret with no group")
+	}
+}
+
+func TestComputeSessionKeysMatchesAcrossPeers(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	priv1, _ := group.GeneratePrivateKey(nil)
+	priv2, _ := group.GeneratePrivateKey(nil)
+	pub1 := NewPublicKey(priv1.MarshalPublicKey())
+	pub2 := NewPublicKey(priv2.MarshalPublicKey())
+
+	keys1, err := ComputeSessionKeys(pub2, priv1, KDF_HKDF_SHA256, []byte("session"), 32)
+	if err != nil {
+		t.Fatalf("ComputeSessionKeys: %v", err)
+	}
+	keys2, err := ComputeSessionKeys(pub1, priv2, KDF_HKDF_SHA256, []byte("session"), 32)
+	if err != nil {
+		t.Fatalf("ComputeSessionKeys: %v", err)
+	}
+	if !bytes.Equal(keys1, keys2) {
+		t.Errorf("expected both peers to derive the same session keys")
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// NewUniformKeyPair generates a private/public key pair using the UniformDH
+// construction used by the obfs3 and ScrambleSuit pluggable transports.
+//
+// A plain DH public key g^x mod p always lands in the subgroup of quadratic
+// residues, which a passive observer can detect and use to fingerprint the
+// handshake. UniformDH avoids this by choosing a private exponent x = 2k for
+// random k in [1, p), publishing y = g^k mod p, and then with 50%
+// probability negating it to p-y before it goes on the wire. Since -1 is a
+// quadratic non-residue for a safe prime, this produces a public value whose
+// residue class is no longer predictable, making it indistinguishable from a
+// uniform random string of length ceil(bitLen(p)/8) once encoded with
+// MarshalUniform.
+//
+// group.p must be a safe prime, i.e. (p-1)/2 must also be prime; this holds
+// for the groups returned by GetGroup, but is not verified for groups built
+// with CreateGroup.
+func NewUniformKeyPair(group *DHGroup, randReader io.Reader) (key *DHKey, err error) {
+	if randReader == nil {
+		randReader = rand.Reader
+	}
+	if group.p == nil {
+		err = errors.New("DH: invalid group")
+		return
+	}
+
+	// k is the discrete log of the value we publish before the possible
+	// negation. The private exponent we actually keep is x = 2k, which is
+	// always even; raising an even power to p-y cancels the sign, so
+	// either party can use x directly in ComputeUniformKey regardless of
+	// which of {y, p-y} the peer happened to send.
+	one := big.NewInt(1)
+	k, err := rand.Int(randReader, new(big.Int).Sub(group.p, one))
+	if err != nil {
+		return
+	}
+	k.Add(k, one)
+
+	y := constantTimeExp(group.g, k, group.p)
+
+	flip := make([]byte, 1)
+	if _, err = io.ReadFull(randReader, flip); err != nil {
+		return
+	}
+	if flip[0]&1 == 1 {
+		y.Sub(group.p, y)
+	}
+
+	key = new(DHKey)
+	key.X = new(big.Int).Lsh(k, 1)
+	key.Y = y
+	key.Group = group
+	return
+}
+
+// ComputeUniformKey computes the shared secret for a UniformDH handshake. It
+// mirrors DHGroup.ComputeKey, but pubkey.Y may be either y or p-y as
+// published by NewUniformKeyPair: since privkey.X is always even for
+// UniformDH keys, Y^x mod p is identical in both cases, so no extra
+// min(Y, p-Y) step is required.
+func (h *DHGroup) ComputeUniformKey(pubkey *DHKey, privkey *DHKey) (key *DHKey, err error) {
+	if err = validateComputeKeyArgs(h, pubkey, privkey); err != nil {
+		return
+	}
+	k := constantTimeExp(pubkey.Y, privkey.X, h.p)
+	key = new(DHKey)
+	key.Y = k
+	key.Group = h
+	return
+}
+
+// MarshalUniform encodes the public half of the key as exactly
+// ceil(bitLen(p)/8) bytes, left-padding with zeros as needed. h.Group must
+// be set, since the fixed output length depends on the modulus; keys
+// returned by NewUniformKeyPair already have it set.
+func (h *DHKey) MarshalUniform() ([]byte, error) {
+	if h.Y == nil {
+		return nil, errors.New("DH: invalid public key")
+	}
+	if h.Group == nil {
+		return nil, errors.New("DH: key has no group, cannot determine uniform length")
+	}
+	return h.MarshalPublicKey(), nil
+}
+
+// UnmarshalUniform parses a UniformDH public value previously produced by
+// MarshalUniform. s must be exactly group's modulus length; enforcing this
+// is what lets the wire format avoid leaking the handshake through a
+// variable-length encoding.
+func UnmarshalUniform(s []byte, group *DHGroup) (key *DHKey, err error) {
+	if group == nil || group.p == nil {
+		err = errors.New("DH: invalid group")
+		return
+	}
+	blen := (group.p.BitLen() + 7) / 8
+	if len(s) != blen {
+		err = fmt.Errorf("DH: uniform public key must be %d bytes, got %d", blen, len(s))
+		return
+	}
+	key = NewPublicKey(s)
+	key.Group = group
+	return
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package dhkx
+
+import (
+	"testing"
+)
+
+func TestUniformDHKeyExchange(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+
+	priv1, err := NewUniformKeyPair(group, nil)
+	if err != nil {
+		t.Fatalf("NewUniformKeyPair: %v", err)
+	}
+	priv2, err := NewUniformKeyPair(group, nil)
+	if err != nil {
+		t.Fatalf("NewUniformKeyPair: %v", err)
+	}
+
+	wire1, err := priv1.MarshalUniform()
+	if err != nil {
+		t.Fatalf("MarshalUniform: %v", err)
+	}
+	wire2, err := priv2.MarshalUniform()
+	if err != nil {
+		t.Fatalf("MarshalUniform: %v", err)
+	}
+
+	blen := (group.P().BitLen() + 7) / 8
+	if len(wire1) != blen || len(wire2) != blen {
+		t.Fatalf("expected uniform encodings of length %d, got %d and %d", blen, len(wire1), len(wire2))
+	}
+
+	pub1, err := UnmarshalUniform(wire1, group)
+	if err != nil {
+		t.Fatalf("UnmarshalUniform: %v", err)
+	}
+	pub2, err := UnmarshalUniform(wire2, group)
+	if err != nil {
+		t.Fatalf("UnmarshalUniform: %v", err)
+	}
+
+	key1, err := group.ComputeUniformKey(pub2, priv1)
+	if err != nil {
+		t.Fatalf("ComputeUniformKey: %v", err)
+	}
+	key2, err := group.ComputeUniformKey(pub1, priv2)
+	if err != nil {
+		t.Fatalf("ComputeUniformKey: %v", err)
+	}
+
+	if key1.Y.Cmp(key2.Y) != 0 {
+		t.Errorf("shared secrets do not match: %s != %s", key1.Y.String(), key2.Y.String())
+	}
+}
+
+func TestUnmarshalUniformWrongLength(t *testing.T) {
+	group, _ := GetGroup(DHKX_ID14)
+	if _, err := UnmarshalUniform([]byte{1, 2, 3}, group); err == nil {
+		t.Errorf("expected an error for a short uniform public key")
+	}
+}
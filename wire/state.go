@@ -0,0 +1,76 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wire
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/WebauthnWorks/dhkx"
+)
+
+// LoadOrGenerate loads a private key previously saved at path, or, if path
+// does not exist, generates a new one for group and saves it there before
+// returning it. This gives a long-running service (e.g. a pluggable
+// transport, following the pattern obfs4proxy uses for its pt_state
+// directory) a stable identity keypair across restarts without requiring
+// any setup beyond picking a path.
+//
+// The file is written with 0600 permissions and replaced atomically (write
+// to a temp file, then rename), so a crash mid-write cannot leave a
+// truncated key file behind. It does not, however, guard against two
+// processes racing to create the file for the first time: both will
+// generate a keypair and write it through the same temporary file
+// (path + ".tmp"), so the key that ends up at path can differ from the one
+// a successful caller holds in memory, and the other caller's rename can
+// fail because the temporary file has already been moved. Callers that run
+// more than one instance against the same path should arrange their own
+// locking or create the file out of band first.
+func LoadOrGenerate(path string, group *dhkx.DHGroup) (*dhkx.DHKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key := new(dhkx.DHKey)
+		if err := json.Unmarshal(data, key); err != nil {
+			return nil, err
+		}
+		key.Group = group
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := group.GeneratePrivateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package wire provides a small binary framing for putting dhkx public keys
+// on the wire, and a LoadOrGenerate helper for persisting an ephemeral
+// keypair to disk between process restarts.
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/WebauthnWorks/dhkx"
+)
+
+// magic identifies the start of a frame produced by Encode, so a decoder can
+// reject anything else (e.g. a stray line from an unrelated protocol)
+// before attempting to parse it.
+var magic = []byte("DHK1")
+
+// Encode frames key for the wire: magic bytes, groupID as a varint, the
+// public key's length as a varint followed by its bytes, and finally mac's
+// length as a varint followed by its bytes (mac may be nil or empty, which
+// encodes as a zero length and nothing else). groupID is carried explicitly
+// because a DHGroup does not otherwise know which dhkx.GroupID it was built
+// from.
+func Encode(groupID dhkx.GroupID, key *dhkx.DHKey, mac []byte) ([]byte, error) {
+	if key == nil || key.Y == nil {
+		return nil, errors.New("wire: invalid key")
+	}
+	if key.Group == nil {
+		return nil, errors.New("wire: key has no group, cannot determine its encoded length")
+	}
+	pub := key.MarshalPublicKey()
+
+	buf := make([]byte, 0, len(magic)+2*binary.MaxVarintLen64+len(pub)+len(mac))
+	buf = append(buf, magic...)
+	buf = appendUvarint(buf, uint64(groupID))
+	buf = appendUvarint(buf, uint64(len(pub)))
+	buf = append(buf, pub...)
+	buf = appendUvarint(buf, uint64(len(mac)))
+	buf = append(buf, mac...)
+	return buf, nil
+}
+
+// Decode parses a frame produced by Encode, looks up groupID via
+// dhkx.GetGroup, and returns the public key bound to that group along with
+// the MAC that was attached to it (nil if none was).
+func Decode(b []byte) (key *dhkx.DHKey, groupID dhkx.GroupID, mac []byte, err error) {
+	if len(b) < len(magic) || !bytes.Equal(b[:len(magic)], magic) {
+		err = errors.New("wire: bad magic")
+		return
+	}
+	b = b[len(magic):]
+
+	gid, n := binary.Uvarint(b)
+	if n <= 0 {
+		err = errors.New("wire: truncated group id")
+		return
+	}
+	b = b[n:]
+
+	pubLen, n := binary.Uvarint(b)
+	if n <= 0 {
+		err = errors.New("wire: truncated public key length")
+		return
+	}
+	b = b[n:]
+	if uint64(len(b)) < pubLen {
+		err = errors.New("wire: truncated public key")
+		return
+	}
+	pub := b[:pubLen]
+	b = b[pubLen:]
+
+	macLen, n := binary.Uvarint(b)
+	if n <= 0 {
+		err = errors.New("wire: truncated mac length")
+		return
+	}
+	b = b[n:]
+	if uint64(len(b)) < macLen {
+		err = errors.New("wire: truncated mac")
+		return
+	}
+	mac = nil
+	if macLen > 0 {
+		mac = b[:macLen]
+	}
+
+	groupID = dhkx.GroupID(gid)
+	group, gerr := dhkx.GetGroup(groupID)
+	if gerr != nil {
+		err = gerr
+		return
+	}
+	if blen := uint64((group.P().BitLen() + 7) / 8); blen != pubLen {
+		err = errors.New("wire: public key length does not match group")
+		return
+	}
+
+	key = dhkx.NewPublicKey(pub)
+	key.Group = group
+	return
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
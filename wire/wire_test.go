@@ -0,0 +1,104 @@
+/*
+ * Copyright 2012 Nan Deng
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package wire
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/WebauthnWorks/dhkx"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	group, _ := dhkx.GetGroup(dhkx.DHKX_ID14)
+	priv, _ := group.GeneratePrivateKey(nil)
+	mac := []byte("a mac")
+
+	framed, err := Encode(dhkx.DHKX_ID14, priv, mac)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	key, groupID, gotMAC, err := Decode(framed)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if groupID != dhkx.DHKX_ID14 {
+		t.Errorf("expected groupID %d, got %d", dhkx.DHKX_ID14, groupID)
+	}
+	if !bytes.Equal(gotMAC, mac) {
+		t.Errorf("expected mac %q, got %q", mac, gotMAC)
+	}
+	if !bytes.Equal(key.MarshalPublicKey(), priv.MarshalPublicKey()) {
+		t.Errorf("decoded public key does not match the original")
+	}
+}
+
+func TestEncodeDecodeWithoutMAC(t *testing.T) {
+	group, _ := dhkx.GetGroup(dhkx.DHKX_ID14)
+	priv, _ := group.GeneratePrivateKey(nil)
+
+	framed, err := Encode(dhkx.DHKX_ID14, priv, nil)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	_, _, mac, err := Decode(framed)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(mac) != 0 {
+		t.Errorf("expected no mac, got %q", mac)
+	}
+}
+
+func TestDecodeRejectsBadMagic(t *testing.T) {
+	if _, _, _, err := Decode([]byte("not a dhkx frame")); err == nil {
+		t.Errorf("expected Decode to reject a frame with the wrong magic")
+	}
+}
+
+func TestLoadOrGenerateIsStable(t *testing.T) {
+	group, _ := dhkx.GetGroup(dhkx.DHKX_ID14)
+	path := filepath.Join(t.TempDir(), "identity.json")
+
+	key1, err := LoadOrGenerate(path, group)
+	if err != nil {
+		t.Fatalf("LoadOrGenerate (first run): %v", err)
+	}
+	key2, err := LoadOrGenerate(path, group)
+	if err != nil {
+		t.Fatalf("LoadOrGenerate (second run): %v", err)
+	}
+
+	if !bytes.Equal(key1.MarshalPublicKey(), key2.MarshalPublicKey()) {
+		t.Errorf("expected LoadOrGenerate to return the same key across runs")
+	}
+
+	shared1, err := group.ComputeKey(dhkx.NewPublicKey(key1.MarshalPublicKey()), key2)
+	if err != nil {
+		t.Fatalf("ComputeKey: %v", err)
+	}
+	shared2, err := group.ComputeKey(dhkx.NewPublicKey(key2.MarshalPublicKey()), key1)
+	if err != nil {
+		t.Fatalf("ComputeKey: %v", err)
+	}
+	if !bytes.Equal(shared1.MarshalPublicKey(), shared2.MarshalPublicKey()) {
+		t.Errorf("loaded key no longer produces a consistent shared secret")
+	}
+}